@@ -0,0 +1,119 @@
+package peer
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/record"
+)
+
+// CertifiedAddrBook is an interface that can be implemented by a peerstore
+// (or other address book) to store and retrieve signed PeerRecords, as
+// exchanged by the libp2p identify protocol.
+//
+// Signed PeerRecords are ordered by their Seq field: a CertifiedAddrBook
+// implementation MUST reject a record whose Seq is not strictly greater
+// than the Seq of the record it already has on file for that peer, to guard
+// against replay of stale or rolled-back records.
+type CertifiedAddrBook interface {
+	// ConsumePeerRecord stores a signed PeerRecord contained in
+	// recordEnvelope, provided that the envelope's PayloadType indicates it
+	// contains a PeerRecord, that the record's PeerID matches the
+	// envelope's signing key, and that its Seq is greater than any
+	// PeerRecord already stored for that peer.
+	//
+	// The ttl is applied to the addresses contained in the record, in the
+	// same manner as AddrBook.AddAddrs.
+	//
+	// ConsumePeerRecord returns true if the record was accepted, and false
+	// if it was ignored, e.g. because it was stale. An error is returned if
+	// the envelope failed to validate, or if the record carries the same Seq
+	// as the stored record but different contents (ErrStaleSeq).
+	ConsumePeerRecord(recordEnvelope *record.Envelope, ttl time.Duration) (accepted bool, err error)
+
+	// GetPeerRecord returns the most recently consumed PeerRecord for the
+	// given peer, wrapped in the routing.Envelope it arrived in, or nil if
+	// no record is on file.
+	GetPeerRecord(id ID) *record.Envelope
+}
+
+// certifiedAddrBookRecord is the bookkeeping a memoryCertifiedAddrBook keeps
+// per peer: the signed envelope as received, the PeerRecord it decodes to
+// (so later records can be compared via MergePeerRecords without
+// re-unmarshalling the envelope), and the time at which the ttl passed to
+// ConsumePeerRecord expires it.
+type certifiedAddrBookRecord struct {
+	envelope  *record.Envelope
+	rec       *PeerRecord
+	expiresAt time.Time
+}
+
+// NewCertifiedAddrBook creates an in-memory CertifiedAddrBook. It tracks
+// only the most recently consumed PeerRecord for each peer, and honors the
+// ttl passed to ConsumePeerRecord the same way AddrBook.AddAddrs does: once
+// ttl has elapsed, GetPeerRecord stops returning the record, as though it
+// had never been consumed.
+func NewCertifiedAddrBook() CertifiedAddrBook {
+	return &memoryCertifiedAddrBook{
+		records: make(map[ID]*certifiedAddrBookRecord),
+	}
+}
+
+type memoryCertifiedAddrBook struct {
+	mu      sync.RWMutex
+	records map[ID]*certifiedAddrBookRecord
+}
+
+func (m *memoryCertifiedAddrBook) ConsumePeerRecord(envelope *record.Envelope, ttl time.Duration) (bool, error) {
+	if envelope == nil {
+		return false, fmt.Errorf("cannot consume a nil envelope")
+	}
+	if !bytes.Equal(envelope.PayloadType, PeerRecordEnvelopePayloadType) &&
+		!bytes.Equal(envelope.PayloadType, peerRecordEnvelopePayloadTypeLegacy) {
+		return false, fmt.Errorf("envelope does not contain a PeerRecord")
+	}
+
+	rec := &PeerRecord{}
+	if err := rec.UnmarshalRecord(envelope.RawPayload); err != nil {
+		return false, fmt.Errorf("failed to unmarshal PeerRecord from envelope: %w", err)
+	}
+	if err := rec.Verify(envelope); err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Seq comparisons must look back at the last-accepted record regardless
+	// of whether its ttl has since lapsed: ttl only governs what
+	// GetPeerRecord returns, not whether replaying an old, low-Seq envelope
+	// can resurrect stale addresses once the current record expires.
+	var existingRec *PeerRecord
+	if existing, ok := m.records[rec.PeerID]; ok {
+		existingRec = existing.rec
+	}
+	if _, accepted, err := MergePeerRecords(existingRec, rec); err != nil {
+		return false, err
+	} else if !accepted {
+		return false, nil
+	}
+	m.records[rec.PeerID] = &certifiedAddrBookRecord{
+		envelope:  envelope,
+		rec:       rec,
+		expiresAt: time.Now().Add(ttl),
+	}
+	return true, nil
+}
+
+func (m *memoryCertifiedAddrBook) GetPeerRecord(id ID) *record.Envelope {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rec, ok := m.records[id]
+	if !ok || !time.Now().Before(rec.expiresAt) {
+		return nil
+	}
+	return rec.envelope
+}
@@ -0,0 +1,152 @@
+package peer_test
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/record"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func signedPeerRecordEnvelope(t *testing.T, priv crypto.PrivKey, id peer.ID, seq uint64) *record.Envelope {
+	t.Helper()
+	rec := &peer.PeerRecord{
+		PeerID: id,
+		Addrs:  []ma.Multiaddr{mustMultiaddr(t, "/ip4/1.2.3.4/tcp/1234")},
+		Seq:    seq,
+	}
+	env, err := rec.Sign(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+// TestCertifiedAddrBookSeqOrdering checks that ConsumePeerRecord accepts a
+// strictly-greater Seq, and rejects a lower-or-equal one, for the same
+// peer.
+func TestCertifiedAddrBookSeqOrdering(t *testing.T) {
+	priv, pub, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ab := peer.NewCertifiedAddrBook()
+
+	accepted, err := ab.ConsumePeerRecord(signedPeerRecordEnvelope(t, priv, id, 10), time.Hour)
+	if err != nil || !accepted {
+		t.Fatalf("expected seq=10 to be accepted, got accepted=%v err=%v", accepted, err)
+	}
+
+	accepted, err = ab.ConsumePeerRecord(signedPeerRecordEnvelope(t, priv, id, 5), time.Hour)
+	if err != nil || accepted {
+		t.Fatalf("expected lower seq=5 to be rejected, got accepted=%v err=%v", accepted, err)
+	}
+
+	accepted, err = ab.ConsumePeerRecord(signedPeerRecordEnvelope(t, priv, id, 10), time.Hour)
+	if err != nil || accepted {
+		t.Fatalf("expected equal seq=10 to be rejected, got accepted=%v err=%v", accepted, err)
+	}
+
+	accepted, err = ab.ConsumePeerRecord(signedPeerRecordEnvelope(t, priv, id, 20), time.Hour)
+	if err != nil || !accepted {
+		t.Fatalf("expected higher seq=20 to be accepted, got accepted=%v err=%v", accepted, err)
+	}
+
+	env := ab.GetPeerRecord(id)
+	if env == nil {
+		t.Fatal("expected GetPeerRecord to return the seq=20 envelope")
+	}
+}
+
+// TestCertifiedAddrBookRejectsPeerIDMismatch checks that ConsumePeerRecord
+// rejects an envelope whose inner PeerRecord.PeerID doesn't match the
+// envelope's signing key.
+func TestCertifiedAddrBookRejectsPeerIDMismatch(t *testing.T) {
+	attackerPriv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	victimID, err := peer.Decode(testPeerIDString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged := &peer.PeerRecord{
+		PeerID: victimID,
+		Addrs:  []ma.Multiaddr{mustMultiaddr(t, "/ip4/1.2.3.4/tcp/1234")},
+		Seq:    1,
+	}
+	env, err := record.MakeEnvelopeWithRecord(attackerPriv, peer.PeerRecordEnvelopeDomain, peer.PeerRecordEnvelopePayloadType, forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ab := peer.NewCertifiedAddrBook()
+	accepted, err := ab.ConsumePeerRecord(env, time.Hour)
+	if err != peer.ErrPeerIdMismatch {
+		t.Fatalf("expected ErrPeerIdMismatch, got %v", err)
+	}
+	if accepted {
+		t.Fatal("expected a forged PeerRecord to not be accepted")
+	}
+}
+
+// TestCertifiedAddrBookTTLExpiry checks that ttl expiry only affects what
+// GetPeerRecord returns, and does not reopen the door to replaying a
+// previously-superseded, lower-Seq envelope once the current record's ttl
+// has lapsed.
+func TestCertifiedAddrBookTTLExpiry(t *testing.T) {
+	priv, pub, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ab := peer.NewCertifiedAddrBook()
+
+	accepted, err := ab.ConsumePeerRecord(signedPeerRecordEnvelope(t, priv, id, 100), 10*time.Millisecond)
+	if err != nil || !accepted {
+		t.Fatalf("expected seq=100 to be accepted, got accepted=%v err=%v", accepted, err)
+	}
+	if ab.GetPeerRecord(id) == nil {
+		t.Fatal("expected GetPeerRecord to return the just-consumed record before its ttl expires")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if ab.GetPeerRecord(id) != nil {
+		t.Fatal("expected GetPeerRecord to return nil once the record's ttl has expired")
+	}
+
+	// Replaying a captured envelope with a lower Seq than what was already
+	// accepted must still be rejected, even though the stored record's ttl
+	// has lapsed: Seq ordering is independent of address-TTL expiry.
+	accepted, err = ab.ConsumePeerRecord(signedPeerRecordEnvelope(t, priv, id, 50), time.Hour)
+	if err != nil || accepted {
+		t.Fatalf("expected replayed seq=50 to be rejected after ttl expiry, got accepted=%v err=%v", accepted, err)
+	}
+	if ab.GetPeerRecord(id) != nil {
+		t.Fatal("expected GetPeerRecord to still return nil after a rejected replay")
+	}
+
+	// A genuinely newer record must still be accepted after expiry.
+	accepted, err = ab.ConsumePeerRecord(signedPeerRecordEnvelope(t, priv, id, 150), time.Hour)
+	if err != nil || !accepted {
+		t.Fatalf("expected seq=150 to be accepted after ttl expiry, got accepted=%v err=%v", accepted, err)
+	}
+	if ab.GetPeerRecord(id) == nil {
+		t.Fatal("expected GetPeerRecord to return the seq=150 envelope")
+	}
+}
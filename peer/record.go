@@ -3,6 +3,7 @@ package peer
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
@@ -14,19 +15,40 @@ import (
 
 func init() {
 	record.RegisterPayloadType(PeerRecordEnvelopePayloadType, &PeerRecord{})
+	// Also register the legacy string-based payload type, so envelopes
+	// produced by peers that predate the multicodec registration still
+	// decode as PeerRecords.
+	record.RegisterPayloadType(peerRecordEnvelopePayloadTypeLegacy, &PeerRecord{})
 }
 
 // The domain string used for peer records contained in a Envelope.
 const PeerRecordEnvelopeDomain = "libp2p-peer-record"
 
-// The type hint used to identify peer records in a Envelope.
-// TODO: register multicodec
-var PeerRecordEnvelopePayloadType = []byte("/libp2p/peer-record")
+// PeerRecordEnvelopePayloadType is the type hint used to identify peer
+// records in a Envelope. It's the registered multicodec for peer records;
+// see https://github.com/multiformats/multicodec/blob/master/table.csv.
+var PeerRecordEnvelopePayloadType = []byte{0x03, 0x01}
+
+// peerRecordEnvelopePayloadTypeLegacy is the string-based payload type that
+// this package used before peer records were registered as a multicodec.
+// It's kept as a recognized alias purely so that envelopes signed by older
+// peers keep decoding; new envelopes are always signed with
+// PeerRecordEnvelopePayloadType, so the network can transition without a
+// flag day.
+var peerRecordEnvelopePayloadTypeLegacy = []byte("/libp2p/peer-record")
 
 // ErrPeerIdMismatch is returned when attempting to sign a PeerRecord using a key that
-// does not match the PeerID contained in the record.
+// does not match the PeerID contained in the record, or when consuming a signed
+// PeerRecord whose inner PeerID does not match the envelope's signing key.
 var ErrPeerIdMismatch = errors.New("signing key does not match record.PeerID")
 
+// ErrStaleSeq is returned by MergePeerRecords when two PeerRecords for the
+// same peer carry the same Seq but have different Addrs. Records that
+// collide like this can't be ordered by Seq alone, which usually means Seq
+// was generated non-monotonically (e.g. after a clock rewind), rather than
+// one of the two simply being a duplicate.
+var ErrStaleSeq = errors.New("peer record has the same sequence number as an existing record, but different contents")
+
 // PeerRecord contains information that is broadly useful to share with other peers,
 // either through a direct exchange (as in the libp2p identify protocol), or through
 // a Peer Routing provider, such as a DHT.
@@ -80,6 +102,12 @@ var ErrPeerIdMismatch = errors.New("signing key does not match record.PeerID")
 //     }
 //     peerRec := untypedRecord.(*PeerRecord)
 //
+// Note that ConsumeEnvelope only verifies the envelope's signature; it does
+// not check that the PeerRecord's PeerID field actually matches the key
+// that signed it. A record obtained this way (or via UnmarshalRecord
+// directly) is untrusted until it's been passed through PeerRecord.Verify,
+// or was obtained via ConsumeVerifiedPeerRecord in the first place.
+//
 type PeerRecord struct {
 	// PeerID is the ID of the peer this record pertains to.
 	PeerID ID
@@ -114,6 +142,29 @@ func TimestampSeq() uint64 {
 	return uint64(time.Now().UnixNano())
 }
 
+var (
+	lastTimestampSeqMu sync.Mutex
+	lastTimestampSeq   uint64
+)
+
+// TimestampSeqMonotonic is like TimestampSeq, but guarantees that the value
+// it returns is strictly greater than any value it has previously returned
+// in this process, even across calls made within the same nanosecond.
+// TimestampSeq alone can collide when called twice in quick succession,
+// which would make a CertifiedAddrBook silently reject the second, entirely
+// legitimate, update as stale.
+func TimestampSeqMonotonic() uint64 {
+	lastTimestampSeqMu.Lock()
+	defer lastTimestampSeqMu.Unlock()
+
+	seq := TimestampSeq()
+	if seq <= lastTimestampSeq {
+		seq = lastTimestampSeq + 1
+	}
+	lastTimestampSeq = seq
+	return seq
+}
+
 // UnmarshalRecord parses a PeerRecord from a byte slice.
 // This method is called automatically when consuming a record.Envelope
 // whose PayloadType indicates that it contains a PeerRecord.
@@ -124,18 +175,14 @@ func (r *PeerRecord) UnmarshalRecord(bytes []byte) error {
 	}
 
 	var msg pb.PeerRecord
-	err := proto.Unmarshal(bytes, &msg)
-	if err != nil {
+	if err := proto.Unmarshal(bytes, &msg); err != nil {
 		return err
 	}
-	var id ID
-	err = id.UnmarshalBinary(msg.PeerId)
+	rec, err := PeerRecordFromProtobuf(&msg)
 	if err != nil {
 		return err
 	}
-	r.PeerID = id
-	r.Addrs = addrsFromProtobuf(msg.Addresses)
-	r.Seq = msg.Seq
+	*r = *rec
 	return nil
 }
 
@@ -143,16 +190,95 @@ func (r *PeerRecord) UnmarshalRecord(bytes []byte) error {
 // This method is called automatically when constructing a routing.Envelope
 // using MakeEnvelopeWithRecord or PeerRecord.Sign.
 func (r *PeerRecord) MarshalRecord() ([]byte, error) {
+	msg, err := r.ToProtobuf()
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+// PeerRecordFromProtobuf converts a *pb.PeerRecord protobuf message into a
+// *PeerRecord. It's useful to protocols like identify that embed a
+// PeerRecord inside their own protobuf messages and would otherwise have to
+// marshal the message back to bytes just to call UnmarshalRecord.
+func PeerRecordFromProtobuf(msg *pb.PeerRecord) (*PeerRecord, error) {
+	if msg == nil {
+		return nil, errors.New("cannot construct a PeerRecord from a nil pb.PeerRecord")
+	}
+	var id ID
+	if err := id.UnmarshalBinary(msg.PeerId); err != nil {
+		return nil, err
+	}
+	return &PeerRecord{
+		PeerID: id,
+		Addrs:  addrsFromProtobuf(msg.Addresses),
+		Seq:    msg.Seq,
+	}, nil
+}
+
+// ToProtobuf converts the PeerRecord to a *pb.PeerRecord protobuf message,
+// the counterpart to PeerRecordFromProtobuf.
+func (r *PeerRecord) ToProtobuf() (*pb.PeerRecord, error) {
 	idBytes, err := r.PeerID.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
-	msg := pb.PeerRecord{
+	return &pb.PeerRecord{
 		PeerId:    idBytes,
 		Addresses: addrsToProtobuf(r.Addrs),
 		Seq:       r.Seq,
+	}, nil
+}
+
+// Verify checks that the PeerRecord's PeerID matches the public key that
+// signed env. It returns ErrPeerIdMismatch if they don't match. Verify does
+// not re-check the envelope's signature; it's meant to be called on a
+// PeerRecord that was already obtained from env via ConsumeEnvelope or
+// UnmarshalRecord.
+func (r *PeerRecord) Verify(env *record.Envelope) error {
+	if env == nil {
+		return fmt.Errorf("cannot verify PeerRecord against a nil envelope")
 	}
-	return proto.Marshal(&msg)
+	if !r.PeerID.MatchesPublicKey(env.PublicKey) {
+		return ErrPeerIdMismatch
+	}
+	return nil
+}
+
+// ConsumeVerifiedPeerRecord unmarshals and validates a signed PeerRecord
+// contained in a serialized routing.Envelope. Unlike ConsumeEnvelope, which
+// will happily return a PeerRecord whose embedded PeerID doesn't match the
+// envelope's signing key, ConsumeVerifiedPeerRecord fails closed: it returns
+// ErrPeerIdMismatch rather than a partially-valid record.
+//
+// If expectedFrom is non-empty, the record is additionally required to
+// belong to that peer, which is useful when envelopeBytes were received
+// over a channel (e.g. identify) that already authenticates the remote
+// PeerID by other means.
+func ConsumeVerifiedPeerRecord(envelopeBytes []byte, expectedFrom ID) (*record.Envelope, *PeerRecord, error) {
+	envelope, untypedRecord, err := record.ConsumeEnvelope(envelopeBytes, PeerRecordEnvelopeDomain)
+	if err != nil {
+		return nil, nil, err
+	}
+	rec, ok := untypedRecord.(*PeerRecord)
+	if !ok {
+		return nil, nil, fmt.Errorf("envelope did not contain a PeerRecord")
+	}
+	if err := rec.Verify(envelope); err != nil {
+		return nil, nil, err
+	}
+	if expectedFrom != "" && expectedFrom != rec.PeerID {
+		return nil, nil, ErrPeerIdMismatch
+	}
+	return envelope, rec, nil
+}
+
+// PayloadType returns the multicodec payload type used to identify a
+// PeerRecord inside a routing.Envelope. New envelopes are always signed
+// using this canonical payload type, even though envelopes carrying the
+// legacy string-based payload type are still accepted on the way in.
+func (r *PeerRecord) PayloadType() []byte {
+	return PeerRecordEnvelopePayloadType
 }
 
 // Sign wraps the PeerRecord in a routing.Envelope, signed with the given
@@ -200,6 +326,47 @@ func (r *PeerRecord) Equal(other *PeerRecord) bool {
 	return true
 }
 
+// Supersedes returns true if r should replace other as the current
+// PeerRecord for their shared peer, i.e. if r.Seq is strictly greater than
+// other.Seq. Supersedes does not itself check that the two records share a
+// PeerID; callers that may be comparing records for different peers should
+// check PeerID first, or use MergePeerRecords instead.
+func (r *PeerRecord) Supersedes(other *PeerRecord) bool {
+	if other == nil {
+		return true
+	}
+	return r.Seq > other.Seq
+}
+
+// MergePeerRecords decides which of existing and incoming should be kept as
+// the current PeerRecord for a peer, enforcing the "newer records must have
+// greater Seq" invariant documented on PeerRecord.
+//
+// It returns (incoming, true, nil) if incoming.Seq is greater than
+// existing.Seq, and (existing, false, nil) if incoming is stale. If the two
+// records carry the same Seq but different Addrs, MergePeerRecords returns
+// ErrStaleSeq rather than silently picking one, since equal Seqs with
+// differing contents indicate the Seq wasn't generated monotonically.
+// existing may be nil, in which case incoming is always kept.
+func MergePeerRecords(existing, incoming *PeerRecord) (*PeerRecord, bool, error) {
+	if incoming == nil {
+		return nil, false, fmt.Errorf("cannot merge a nil incoming PeerRecord")
+	}
+	if existing == nil {
+		return incoming, true, nil
+	}
+	if existing.PeerID != incoming.PeerID {
+		return nil, false, fmt.Errorf("cannot merge PeerRecords belonging to different peers")
+	}
+	if incoming.Supersedes(existing) {
+		return incoming, true, nil
+	}
+	if incoming.Seq == existing.Seq && !incoming.Equal(existing) {
+		return nil, false, ErrStaleSeq
+	}
+	return existing, false, nil
+}
+
 func addrsFromProtobuf(addrs []*pb.PeerRecord_AddressInfo) []ma.Multiaddr {
 	var out []ma.Multiaddr
 	for _, addr := range addrs {
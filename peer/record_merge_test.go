@@ -0,0 +1,123 @@
+package peer_test
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+func newTestRecord(seq uint64) *peer.PeerRecord {
+	return &peer.PeerRecord{
+		PeerID: peer.ID("test-peer"),
+		Seq:    seq,
+	}
+}
+
+// TestMergePeerRecordsAcceptsHigherSeq checks that MergePeerRecords keeps
+// incoming, and reports it as accepted, when its Seq is strictly greater
+// than existing's.
+func TestMergePeerRecordsAcceptsHigherSeq(t *testing.T) {
+	existing := newTestRecord(10)
+	incoming := newTestRecord(20)
+
+	kept, accepted, err := peer.MergePeerRecords(existing, incoming)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !accepted {
+		t.Fatal("expected a strictly greater Seq to be accepted")
+	}
+	if kept != incoming {
+		t.Fatal("expected the higher-Seq record to be kept")
+	}
+}
+
+// TestMergePeerRecordsRejectsLowerSeq checks that MergePeerRecords keeps
+// existing, and reports incoming as not accepted, when incoming's Seq is
+// strictly lower.
+func TestMergePeerRecordsRejectsLowerSeq(t *testing.T) {
+	existing := newTestRecord(20)
+	incoming := newTestRecord(10)
+
+	kept, accepted, err := peer.MergePeerRecords(existing, incoming)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted {
+		t.Fatal("expected a strictly lower Seq to be rejected")
+	}
+	if kept != existing {
+		t.Fatal("expected the existing record to be kept")
+	}
+}
+
+// TestMergePeerRecordsStaleSeq checks that MergePeerRecords returns
+// ErrStaleSeq, rather than silently picking one side, when existing and
+// incoming share a Seq but have different Addrs.
+func TestMergePeerRecordsStaleSeq(t *testing.T) {
+	existing := newTestRecord(10)
+	existing.Addrs = append(existing.Addrs, mustMultiaddr(t, "/ip4/1.2.3.4/tcp/1234"))
+	incoming := newTestRecord(10)
+	incoming.Addrs = append(incoming.Addrs, mustMultiaddr(t, "/ip4/5.6.7.8/tcp/5678"))
+
+	_, accepted, err := peer.MergePeerRecords(existing, incoming)
+	if err != peer.ErrStaleSeq {
+		t.Fatalf("expected ErrStaleSeq, got %v", err)
+	}
+	if accepted {
+		t.Fatal("expected a stale-seq conflict to not be accepted")
+	}
+}
+
+// TestMergePeerRecordsSameSeqSameContents checks that MergePeerRecords
+// treats an exact duplicate (same Seq, same Addrs) as a no-op rather than
+// ErrStaleSeq.
+func TestMergePeerRecordsSameSeqSameContents(t *testing.T) {
+	existing := newTestRecord(10)
+	incoming := newTestRecord(10)
+
+	kept, accepted, err := peer.MergePeerRecords(existing, incoming)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted {
+		t.Fatal("expected a duplicate record to not be accepted")
+	}
+	if kept != existing {
+		t.Fatal("expected the existing record to be kept")
+	}
+}
+
+// TestPeerRecordSupersedes checks the Seq comparison Supersedes is meant to
+// encapsulate, including the nil-other convenience case.
+func TestPeerRecordSupersedes(t *testing.T) {
+	older := newTestRecord(10)
+	newer := newTestRecord(20)
+
+	if !newer.Supersedes(older) {
+		t.Fatal("expected a higher Seq to supersede a lower one")
+	}
+	if older.Supersedes(newer) {
+		t.Fatal("expected a lower Seq to not supersede a higher one")
+	}
+	if older.Supersedes(older) {
+		t.Fatal("expected equal Seqs to not supersede one another")
+	}
+	if !older.Supersedes(nil) {
+		t.Fatal("expected any record to supersede a nil record")
+	}
+}
+
+// TestTimestampSeqMonotonicIncreasing checks that TimestampSeqMonotonic
+// returns strictly increasing values even when called in a tight loop,
+// where TimestampSeq alone can collide within the same nanosecond.
+func TestTimestampSeqMonotonicIncreasing(t *testing.T) {
+	last := peer.TimestampSeqMonotonic()
+	for i := 0; i < 10000; i++ {
+		next := peer.TimestampSeqMonotonic()
+		if next <= last {
+			t.Fatalf("expected strictly increasing values, got %d after %d", next, last)
+		}
+		last = next
+	}
+}
@@ -0,0 +1,228 @@
+package peer_test
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/record"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+const testPeerIDString = "QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSAoAfe"
+
+// legacyPeerRecordEnvelopePayloadType is the pre-multicodec payload type
+// that PeerRecordEnvelopePayloadType used to be. It's hardcoded here,
+// rather than referencing the unexported alias that peer registers
+// internally, so this test exercises the same black-box decoding path a
+// peer on the old wire format would.
+var legacyPeerRecordEnvelopePayloadType = []byte("/libp2p/peer-record")
+
+func mustMultiaddr(t *testing.T, s string) ma.Multiaddr {
+	t.Helper()
+	a, err := ma.NewMultiaddr(s)
+	if err != nil {
+		t.Fatalf("failed to parse multiaddr %q: %s", s, err)
+	}
+	return a
+}
+
+func testPeerRecord(t *testing.T) *peer.PeerRecord {
+	t.Helper()
+	id, err := peer.Decode(testPeerIDString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &peer.PeerRecord{
+		PeerID: id,
+		Addrs: []ma.Multiaddr{
+			mustMultiaddr(t, "/ip4/1.2.3.4/tcp/1234"),
+			mustMultiaddr(t, "/ip4/5.6.7.8/tcp/5678"),
+			mustMultiaddr(t, "/ip6/::1/tcp/1234"),
+		},
+		Seq: 42,
+	}
+}
+
+// TestPeerRecordProtobufRoundTrip checks that converting a PeerRecord to its
+// protobuf form and back preserves address ordering and Seq.
+func TestPeerRecordProtobufRoundTrip(t *testing.T) {
+	rec := testPeerRecord(t)
+
+	msg, err := rec.ToProtobuf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec2, err := peer.PeerRecordFromProtobuf(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Equal(rec2) {
+		t.Fatal("round-tripped PeerRecord does not equal original")
+	}
+	for i, a := range rec2.Addrs {
+		if !a.Equal(rec.Addrs[i]) {
+			t.Fatalf("address order not preserved: expected %s at index %d, got %s", rec.Addrs[i], i, a)
+		}
+	}
+}
+
+// TestPeerRecordFromProtobufNil checks that PeerRecordFromProtobuf returns
+// an error instead of panicking when given a nil message, which callers
+// that embed an optional *pb.PeerRecord sub-message can otherwise pass in.
+func TestPeerRecordFromProtobufNil(t *testing.T) {
+	if _, err := peer.PeerRecordFromProtobuf(nil); err == nil {
+		t.Fatal("expected an error constructing a PeerRecord from a nil pb.PeerRecord")
+	}
+}
+
+// TestPeerRecordMarshalUnmarshalRoundTrip checks that MarshalRecord and
+// UnmarshalRecord, which now delegate to ToProtobuf/PeerRecordFromProtobuf,
+// still round-trip a PeerRecord's address ordering and Seq.
+func TestPeerRecordMarshalUnmarshalRoundTrip(t *testing.T) {
+	rec := testPeerRecord(t)
+
+	data, err := rec.MarshalRecord()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec2 := &peer.PeerRecord{}
+	if err := rec2.UnmarshalRecord(data); err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Equal(rec2) {
+		t.Fatal("unmarshalled PeerRecord does not equal original")
+	}
+}
+
+// TestPeerRecordLegacyPayloadTypeAlias checks that an envelope signed with
+// the pre-multicodec string payload type still decodes to an equivalent
+// PeerRecord as one signed with the canonical multicodec payload type, so
+// that peers can transition between the two without a flag day.
+func TestPeerRecordLegacyPayloadTypeAlias(t *testing.T) {
+	priv, pub, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &peer.PeerRecord{
+		PeerID: id,
+		Addrs:  []ma.Multiaddr{mustMultiaddr(t, "/ip4/1.2.3.4/tcp/1234")},
+		Seq:    7,
+	}
+
+	canonicalEnv, err := rec.Sign(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonicalBytes, err := canonicalEnv.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacyEnv, err := record.MakeEnvelopeWithRecord(priv, peer.PeerRecordEnvelopeDomain, legacyPeerRecordEnvelopePayloadType, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyBytes, err := legacyEnv.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, canonicalUntyped, err := record.ConsumeEnvelope(canonicalBytes, peer.PeerRecordEnvelopeDomain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, legacyUntyped, err := record.ConsumeEnvelope(legacyBytes, peer.PeerRecordEnvelopeDomain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canonicalRec, ok := canonicalUntyped.(*peer.PeerRecord)
+	if !ok {
+		t.Fatal("expected envelope to decode to a *peer.PeerRecord")
+	}
+	legacyRec, ok := legacyUntyped.(*peer.PeerRecord)
+	if !ok {
+		t.Fatal("expected envelope to decode to a *peer.PeerRecord")
+	}
+	if !canonicalRec.Equal(legacyRec) {
+		t.Fatal("PeerRecords decoded from canonical and legacy payload types are not equal")
+	}
+}
+
+// TestConsumeVerifiedPeerRecordRejectsForgedPeerID checks that an envelope
+// signed by one peer but claiming another peer's PeerID in its PeerRecord
+// is rejected by both PeerRecord.Verify and ConsumeVerifiedPeerRecord,
+// instead of being returned as a valid record for the claimed PeerID.
+func TestConsumeVerifiedPeerRecordRejectsForgedPeerID(t *testing.T) {
+	attackerPriv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	victimID, err := peer.Decode(testPeerIDString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged := &peer.PeerRecord{
+		PeerID: victimID,
+		Addrs:  []ma.Multiaddr{mustMultiaddr(t, "/ip4/1.2.3.4/tcp/1234")},
+		Seq:    1,
+	}
+	env, err := record.MakeEnvelopeWithRecord(attackerPriv, peer.PeerRecordEnvelopeDomain, peer.PeerRecordEnvelopePayloadType, forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := forged.Verify(env); err != peer.ErrPeerIdMismatch {
+		t.Fatalf("expected ErrPeerIdMismatch from Verify, got %v", err)
+	}
+
+	envBytes, err := env.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := peer.ConsumeVerifiedPeerRecord(envBytes, ""); err != peer.ErrPeerIdMismatch {
+		t.Fatalf("expected ErrPeerIdMismatch from ConsumeVerifiedPeerRecord, got %v", err)
+	}
+}
+
+// TestConsumeVerifiedPeerRecordRejectsUnexpectedPeer checks that a
+// correctly-signed envelope is still rejected when the caller specifies an
+// expectedFrom PeerID that doesn't match the record.
+func TestConsumeVerifiedPeerRecordRejectsUnexpectedPeer(t *testing.T) {
+	priv, pub, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &peer.PeerRecord{
+		PeerID: id,
+		Addrs:  []ma.Multiaddr{mustMultiaddr(t, "/ip4/1.2.3.4/tcp/1234")},
+		Seq:    1,
+	}
+	envBytes, err := rec.MarshalSigned(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherID, err := peer.Decode(testPeerIDString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := peer.ConsumeVerifiedPeerRecord(envBytes, otherID); err != peer.ErrPeerIdMismatch {
+		t.Fatalf("expected ErrPeerIdMismatch for mismatched expectedFrom, got %v", err)
+	}
+	if _, _, err := peer.ConsumeVerifiedPeerRecord(envBytes, id); err != nil {
+		t.Fatalf("expected no error when expectedFrom matches the record's PeerID, got %v", err)
+	}
+}